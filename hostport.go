@@ -11,6 +11,7 @@ import (
 
 	"github.com/miekg/dns"
 	core "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
@@ -29,9 +30,11 @@ type KubeHostport struct {
 	ttl  uint32
 
 	// Kubernetes API interface
-	client     kubernetes.Interface
-	controller cache.Controller
-	indexer    cache.Indexer
+	client         kubernetes.Interface
+	controller     cache.Controller
+	indexer        cache.Indexer
+	nodeController cache.Controller
+	nodeIndexer    cache.Indexer
 
 	// selectors to filter pods
 	namespace      string
@@ -39,6 +42,26 @@ type KubeHostport struct {
 	labelVal       string
 	strictHostPort bool
 
+	// node-informed response options
+	nodeAddressType   core.NodeAddressType
+	skipUnschedulable bool
+
+	// source selects what setWatch watches: pods (default) or the
+	// EndpointSlices of endpointServiceName.
+	source              sourceMode
+	endpointServiceName string
+
+	// answer ordering for A/AAAA responses
+	policy     policy
+	maxAnswers int
+	rrMu       sync.Mutex
+	rrCounters map[string]*uint32
+
+	// readiness gating, see podReady
+	readinessMode          readinessMode
+	readinessContainerName string
+	readinessConditionType core.PodConditionType
+
 	// concurrency control to stop controller
 	stopLock sync.Mutex
 	shutdown bool
@@ -53,6 +76,10 @@ func New(zones []string) *KubeHostport {
 	k.namespace = defaultNamespace
 	k.labelKey = defaultLabelKey
 	k.labelVal = defaultLabelVal
+	k.source = sourcePods
+	k.policy = policySequential
+	k.rrCounters = make(map[string]*uint32)
+	k.readinessMode = readinessPod
 	k.stopCh = make(chan struct{})
 	return k
 }
@@ -71,11 +98,24 @@ const (
 	defaultLabelVal = ""
 )
 
+// sourceMode selects what kind of Kubernetes object setWatch watches.
+type sourceMode string
+
+const (
+	// sourcePods watches Pods matching labelKey/labelVal (the default).
+	sourcePods sourceMode = "pods"
+
+	// sourceEndpointSlices watches the EndpointSlices of endpointServiceName
+	// instead, reusing Kubernetes' own Service readiness computation.
+	sourceEndpointSlices sourceMode = "endpointslices"
+)
+
 // Name implements the Handler interface.
 func (k *KubeHostport) Name() string { return pluginName }
 
 // ServeDNS implements the plugin.Handler interface.
-func (k *KubeHostport) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+func (k *KubeHostport) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (rcode int, err error) {
+	start := time.Now()
 	state := request.Request{W: w, Req: r}
 
 	qname := state.Name()
@@ -83,14 +123,30 @@ func (k *KubeHostport) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dn
 	if zone == "" || !supportedQtype(state.QType()) {
 		return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, r)
 	}
+	// metricZone is one of the fixed, lower-cased zones from k.Zones, used
+	// for Prometheus labels so a client varying query case can't mint
+	// unbounded label combinations. zone itself keeps the query's original
+	// case below for state.Zone and name slicing.
+	metricZone := zone
 	zone = state.QName()[len(qname)-len(zone):] // maintain case of original query
 	state.Zone = zone
 
+	defer func() {
+		qtype := dns.TypeToString[state.QType()]
+		requestDuration.WithLabelValues(metricZone, qtype).Observe(time.Since(start).Seconds())
+		requestCount.WithLabelValues(metricZone, qtype, dns.RcodeToString[rcode]).Inc()
+	}()
+
 	if len(zone) == len(qname) {
 		writeResponse(w, r, nil, nil, []dns.RR{k.soa()}, dns.RcodeSuccess)
 		return dns.RcodeSuccess, nil
 	}
 
+	// handle SRV lookups of the form _service._proto.zone
+	if state.QType() == dns.TypeSRV {
+		return k.serveSRV(ctx, w, r, state, qname, zone)
+	}
+
 	// handle reverse lookups
 	if state.QType() == dns.TypePTR {
 		if addr := dnsutil.ExtractAddressFromReverse(qname); addr != "" {
@@ -107,19 +163,17 @@ func (k *KubeHostport) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dn
 			}
 			var records []dns.RR
 			for _, obj := range objs {
-				pod, ok := obj.(*core.Pod)
-				if !ok {
-					return dns.RcodeServerFailure, fmt.Errorf("unexpected %q from *Pod index", reflect.TypeOf(obj))
+				name, err := k.recordName(obj)
+				if err != nil {
+					return dns.RcodeServerFailure, err
 				}
-
-				labelKey := k.getPodLabelValueWithNamespace(pod)
-				if labelKey == "" {
+				if name == "" {
 					continue
 				}
 
 				records = append(records, &dns.PTR{
 					Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: k.ttl},
-					Ptr: dnsutil.Join(labelKey, k.Zones[0]),
+					Ptr: dnsutil.Join(name, k.Zones[0]),
 				})
 			}
 			writeResponse(w, r, records, nil, nil, dns.RcodeSuccess)
@@ -147,49 +201,49 @@ func (k *KubeHostport) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dn
 	}
 
 	// build response records
-	var records []dns.RR
-	switch state.QType() {
-	case dns.TypeA:
+	var answers []weightedAnswer
+	wantV6 := state.QType() == dns.TypeAAAA
+	if wantV6 || state.QType() == dns.TypeA {
 		for _, obj := range objs {
-			pod, ok := obj.(*core.Pod)
-			if !ok {
-				return dns.RcodeServerFailure, fmt.Errorf("unexpected %q from *Pod index", reflect.TypeOf(obj))
-			}
-
-			if pod.Status.Phase != core.PodRunning {
-				continue
-			}
-
-			if strings.Contains(pod.Status.HostIP, ":") {
-				continue
-			}
-			if netIP := net.ParseIP(pod.Status.HostIP); netIP != nil {
-				records = append(records, &dns.A{A: netIP,
-					Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: k.ttl}})
-			}
-		}
-	case dns.TypeAAAA:
-		for _, obj := range objs {
-			pod, ok := obj.(*core.Pod)
-			if !ok {
-				return dns.RcodeServerFailure, fmt.Errorf("unexpected %q from *Pod index", reflect.TypeOf(obj))
-			}
-
-			if pod.Status.Phase != core.PodRunning {
-				continue
+			addrs, err := k.recordAddresses(obj)
+			if err != nil {
+				return dns.RcodeServerFailure, err
 			}
 
-			if !strings.Contains(pod.Status.HostIP, ":") {
-				continue
-			}
+			weight := recordWeight(obj)
+			for _, addr := range addrs {
+				netIP := net.ParseIP(addr)
+				if netIP == nil {
+					// addr is a hostname (e.g. from nodeAddressType
+					// Hostname) rather than an IP literal - answer with a
+					// CNAME instead of silently dropping the record.
+					answers = append(answers, weightedAnswer{
+						rr: &dns.CNAME{
+							Hdr:    dns.RR_Header{Name: qname, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: k.ttl},
+							Target: dns.Fqdn(addr),
+						},
+						weight: weight,
+					})
+					continue
+				}
+				if strings.Contains(addr, ":") != wantV6 {
+					continue
+				}
 
-			if netIP := net.ParseIP(pod.Status.HostIP); netIP != nil {
-				records = append(records, &dns.AAAA{AAAA: netIP,
-					Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: k.ttl}})
+				var rr dns.RR
+				if wantV6 {
+					rr = &dns.AAAA{AAAA: netIP,
+						Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: k.ttl}}
+				} else {
+					rr = &dns.A{A: netIP,
+						Hdr: dns.RR_Header{Name: qname, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: k.ttl}}
+				}
+				answers = append(answers, weightedAnswer{rr: rr, weight: weight})
 			}
 		}
 	}
 
+	records := k.applyPolicy(qname, answers)
 	writeResponse(w, r, records, nil, nil, dns.RcodeSuccess)
 	return dns.RcodeSuccess, nil
 }
@@ -220,12 +274,211 @@ func (k *KubeHostport) soa() *dns.SOA {
 
 func supportedQtype(qtype uint16) bool {
 	switch qtype {
-	case dns.TypeA, dns.TypeAAAA, dns.TypePTR:
+	case dns.TypeA, dns.TypeAAAA, dns.TypePTR, dns.TypeSRV:
 		return true
 	default:
 		return false
 	}
 }
 
+// parseSRVName splits a query name relative to the zone (e.g. "_myservice._tcp")
+// into its service and proto components. ok is false if name isn't a
+// well-formed "_service._proto" SRV query.
+func parseSRVName(name string) (service, proto string, ok bool) {
+	labels := dns.SplitDomainName(name)
+	if len(labels) != 2 {
+		return "", "", false
+	}
+	if !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+		return "", "", false
+	}
+	return strings.TrimPrefix(labels[0], "_"), strings.TrimPrefix(labels[1], "_"), true
+}
+
+// serveSRV answers SRV queries of the form _service._proto.zone, resolving
+// to the (HostIP, HostPort) tuples of pods whose label value matches service
+// and that declare a container port with a matching protocol and a non-zero
+// HostPort. A/AAAA glue for each SRV Target is included in the Additional
+// section.
+func (k *KubeHostport) serveSRV(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request, qname, zone string) (int, error) {
+	srvName := qname[0 : len(qname)-len(zone)]
+	srvName = strings.TrimSuffix(srvName, ".")
+
+	service, proto, ok := parseSRVName(srvName)
+	if !ok {
+		writeResponse(w, r, nil, nil, []dns.RR{k.soa()}, dns.RcodeNameError)
+		return dns.RcodeNameError, nil
+	}
+
+	// SRV answers are built from container HostPorts, which EndpointSlices
+	// (backed by Kubernetes Services) have no equivalent of.
+	if k.source == sourceEndpointSlices {
+		if k.Fall.Through(state.Name()) {
+			return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, r)
+		}
+		writeResponse(w, r, nil, nil, []dns.RR{k.soa()}, dns.RcodeNameError)
+		return dns.RcodeNameError, nil
+	}
+
+	objs, err := k.indexer.ByIndex("srv", srvIndexKey(service, proto))
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	if len(objs) == 0 {
+		if k.Fall.Through(state.Name()) {
+			return plugin.NextOrFailure(k.Name(), k.Next, ctx, w, r)
+		}
+		writeResponse(w, r, nil, nil, []dns.RR{k.soa()}, dns.RcodeNameError)
+		return dns.RcodeNameError, nil
+	}
+
+	var records, extra []dns.RR
+	for _, obj := range objs {
+		pod, ok := obj.(*core.Pod)
+		if !ok {
+			return dns.RcodeServerFailure, fmt.Errorf("unexpected %q from *Pod index", reflect.TypeOf(obj))
+		}
+
+		hostIP := k.podHostIP(pod)
+		netIP := net.ParseIP(hostIP)
+
+		// Target must be unique per pod: with the normal case of several
+		// host-networked replicas sharing a label value, a shared Target
+		// would leave the resolver unable to tell which glue IP backs
+		// which port.
+		target := dnsutil.Join(pod.Name, k.getPodLabelValueWithNamespace(pod), k.Zones[0])
+
+		for _, c := range pod.Spec.Containers {
+			for _, port := range c.Ports {
+				if port.HostPort == 0 || !portMatchesProto(port, proto) {
+					continue
+				}
+
+				records = append(records, &dns.SRV{
+					Hdr:      dns.RR_Header{Name: qname, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: k.ttl},
+					Priority: 0,
+					Weight:   0,
+					Port:     uint16(port.HostPort),
+					Target:   target,
+				})
+			}
+		}
+
+		switch {
+		case netIP == nil:
+			// hostIP is a hostname (e.g. from nodeAddressType Hostname)
+			// rather than an IP literal - glue it with a CNAME instead.
+			extra = append(extra, &dns.CNAME{Hdr: dns.RR_Header{Name: target, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: k.ttl}, Target: dns.Fqdn(hostIP)})
+		case strings.Contains(hostIP, ":"):
+			extra = append(extra, &dns.AAAA{Hdr: dns.RR_Header{Name: target, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: k.ttl}, AAAA: netIP})
+		default:
+			extra = append(extra, &dns.A{Hdr: dns.RR_Header{Name: target, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: k.ttl}, A: netIP})
+		}
+	}
+
+	writeResponse(w, r, records, extra, nil, dns.RcodeSuccess)
+	return dns.RcodeSuccess, nil
+}
+
+// portMatchesProto reports whether a ContainerPort's protocol (defaulting to
+// TCP, as Kubernetes does) matches the given SRV proto label.
+func portMatchesProto(port core.ContainerPort, proto string) bool {
+	p := port.Protocol
+	if p == "" {
+		p = core.ProtocolTCP
+	}
+	return strings.EqualFold(string(p), proto)
+}
+
+// srvIndexKey builds the "srv" indexer key for a given service name and proto.
+func srvIndexKey(service, proto string) string {
+	return strings.ToLower(service) + "/" + strings.ToLower(proto)
+}
+
+// recordName returns the name to use as a PTR target for obj, which is
+// either a *core.Pod or, in sourceEndpointSlices mode, a
+// *discoveryv1.EndpointSlice.
+func (k *KubeHostport) recordName(obj interface{}) (string, error) {
+	switch o := obj.(type) {
+	case *core.Pod:
+		return k.getPodLabelValueWithNamespace(o), nil
+	case *discoveryv1.EndpointSlice:
+		return k.endpointServiceName + "." + o.Namespace, nil
+	default:
+		return "", fmt.Errorf("unexpected %q from index", reflect.TypeOf(obj))
+	}
+}
+
+// recordAddresses returns the addresses obj should answer A/AAAA queries
+// with. For a Pod this is its (possibly node-resolved) HostIP, gated on the
+// pod being Running. For an EndpointSlice this is every Ready endpoint's
+// addresses, mirroring the Ready semantics Kubernetes Services already use.
+func (k *KubeHostport) recordAddresses(obj interface{}) ([]string, error) {
+	switch o := obj.(type) {
+	case *core.Pod:
+		if o.Status.Phase != core.PodRunning {
+			return nil, nil
+		}
+		return []string{k.podHostIP(o)}, nil
+	case *discoveryv1.EndpointSlice:
+		var addrs []string
+		for _, ep := range o.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			addrs = append(addrs, ep.Addresses...)
+		}
+		return addrs, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q from index", reflect.TypeOf(obj))
+	}
+}
+
+// podHostIP returns the address to answer with for pod. If nodeAddressType
+// is configured it looks up the pod's node and returns the address of that
+// type, falling back to pod.Status.HostIP if the node or address isn't
+// found. With nodeAddressType Hostname this is a DNS name rather than an IP
+// literal; callers answer such addresses with a CNAME instead of net.ParseIP'ing them.
+func (k *KubeHostport) podHostIP(pod *core.Pod) string {
+	if k.nodeAddressType == "" {
+		return pod.Status.HostIP
+	}
+
+	node, ok := k.getNode(pod.Spec.NodeName)
+	if !ok {
+		return pod.Status.HostIP
+	}
+
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == k.nodeAddressType {
+			return addr.Address
+		}
+	}
+
+	return pod.Status.HostIP
+}
+
+// getNode returns the Node named name from the node indexer.
+func (k *KubeHostport) getNode(name string) (*core.Node, bool) {
+	obj, exists, err := k.nodeIndexer.GetByKey(name)
+	if err != nil || !exists {
+		return nil, false
+	}
+
+	node, ok := obj.(*core.Node)
+	return node, ok
+}
+
+// nodeReady reports whether node has a NodeReady condition with status True.
+func nodeReady(node *core.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == core.NodeReady {
+			return c.Status == core.ConditionTrue
+		}
+	}
+	return false
+}
+
 // Ready implements the ready.Readiness interface.
 func (k *KubeHostport) Ready() bool { return k.controller.HasSynced() }
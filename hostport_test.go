@@ -0,0 +1,83 @@
+package kubehostport
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	core "k8s.io/api/core/v1"
+)
+
+func TestParseSRVName(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantService string
+		wantProto   string
+		wantOK      bool
+	}{
+		{"_myservice._tcp", "myservice", "tcp", true},
+		{"_myservice._udp", "myservice", "udp", true},
+		{"myservice._tcp", "", "", false},
+		{"_myservice.tcp", "", "", false},
+		{"_myservice", "", "", false},
+		{"_a._b._c", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tc := range tests {
+		service, proto, ok := parseSRVName(tc.name)
+		if ok != tc.wantOK || service != tc.wantService || proto != tc.wantProto {
+			t.Errorf("parseSRVName(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tc.name, service, proto, ok, tc.wantService, tc.wantProto, tc.wantOK)
+		}
+	}
+}
+
+func TestPortMatchesProto(t *testing.T) {
+	tests := []struct {
+		name  string
+		port  core.ContainerPort
+		proto string
+		want  bool
+	}{
+		{"explicit TCP matches tcp", core.ContainerPort{Protocol: core.ProtocolTCP}, "tcp", true},
+		{"explicit TCP matches TCP case-insensitively", core.ContainerPort{Protocol: core.ProtocolTCP}, "TCP", true},
+		{"explicit UDP matches udp", core.ContainerPort{Protocol: core.ProtocolUDP}, "udp", true},
+		{"explicit UDP does not match tcp", core.ContainerPort{Protocol: core.ProtocolUDP}, "tcp", false},
+		{"unset protocol defaults to TCP", core.ContainerPort{}, "tcp", true},
+		{"unset protocol does not match udp", core.ContainerPort{}, "udp", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := portMatchesProto(tc.port, tc.proto); got != tc.want {
+				t.Errorf("portMatchesProto(%+v, %q) = %v, want %v", tc.port, tc.proto, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSrvIndexKey(t *testing.T) {
+	if got, want := srvIndexKey("MyService", "TCP"), "myservice/tcp"; got != want {
+		t.Errorf("srvIndexKey() = %q, want %q", got, want)
+	}
+}
+
+func TestSupportedQtype(t *testing.T) {
+	tests := []struct {
+		qtype uint16
+		want  bool
+	}{
+		{dns.TypeA, true},
+		{dns.TypeAAAA, true},
+		{dns.TypePTR, true},
+		{dns.TypeSRV, true},
+		{dns.TypeMX, false},
+		{dns.TypeTXT, false},
+	}
+
+	for _, tc := range tests {
+		if got := supportedQtype(tc.qtype); got != tc.want {
+			t.Errorf("supportedQtype(%v) = %v, want %v", dns.TypeToString[tc.qtype], got, tc.want)
+		}
+	}
+}
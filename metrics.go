@@ -0,0 +1,89 @@
+package kubehostport
+
+import (
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Metrics exported by the coreha plugin. They are registered once per
+// process (see setup.go) so they survive Corefile reloads.
+var (
+	requestCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "requests_total",
+		Help:      "Counter of DNS requests handled by the coreha plugin, by zone, qtype and rcode.",
+	}, []string{"zone", "qtype", "rcode"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "request_duration_seconds",
+		Buckets:   plugin.TimeBuckets,
+		Help:      "Histogram of the time (in seconds) each ServeDNS request took, by zone and qtype.",
+	}, []string{"zone", "qtype"})
+
+	podsIndexed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "pods_indexed",
+		Help:      "Gauge of objects currently held in the plugin's indexer, labelled with the watched namespace (empty for all namespaces).",
+	}, []string{"namespace"})
+
+	podsFilteredCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "pods_filtered_total",
+		Help:      "Counter of pods rejected by checkPodRequirements, by reason.",
+	}, []string{"reason"})
+
+	informerEventCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_events_total",
+		Help:      "Counter of add/update/delete events seen by the plugin's informer.",
+	}, []string{"event"})
+)
+
+// indexerEventHandlers returns the ResourceEventHandlerFuncs shared by the
+// Pod and EndpointSlice informers: they keep informerEventCount and
+// podsIndexed up to date.
+func (k *KubeHostport) indexerEventHandlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			informerEventCount.WithLabelValues("add").Inc()
+			k.recordPodFilterReason(obj)
+			k.recordIndexSize()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			informerEventCount.WithLabelValues("update").Inc()
+			k.recordPodFilterReason(newObj)
+			k.recordIndexSize()
+		},
+		DeleteFunc: func(obj interface{}) {
+			informerEventCount.WithLabelValues("delete").Inc()
+			k.recordIndexSize()
+		},
+	}
+}
+
+// recordPodFilterReason increments podsFilteredCount at most once per Pod
+// Add/Update event. checkPodRequirements is also called from the pod
+// indexer's IndexFuncs (once per registered index), so it must stay a pure
+// predicate and this is the only place that turns its verdict into a metric.
+func (k *KubeHostport) recordPodFilterReason(obj interface{}) {
+	pod, isPod := obj.(*core.Pod)
+	if !isPod {
+		return
+	}
+	if ok, reason := k.checkPodRequirements(pod); !ok {
+		podsFilteredCount.WithLabelValues(reason).Inc()
+	}
+}
+
+// recordIndexSize refreshes the podsIndexed gauge from the current indexer.
+func (k *KubeHostport) recordIndexSize() {
+	podsIndexed.WithLabelValues(k.namespace).Set(float64(len(k.indexer.ListKeys())))
+}
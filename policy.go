@@ -0,0 +1,143 @@
+package kubehostport
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+	core "k8s.io/api/core/v1"
+)
+
+// policy selects how matching A/AAAA answers are ordered/trimmed before
+// they're written to the response.
+type policy string
+
+const (
+	// policySequential leaves answers in indexer order (the default).
+	policySequential policy = "sequential"
+
+	// policyRoundRobin rotates the answer slice per qname on each query.
+	policyRoundRobin policy = "round_robin"
+
+	// policyRandom shuffles the answer slice.
+	policyRandom policy = "random"
+
+	// policyWeighted uses weighted reservoir sampling, keyed off the
+	// weightAnnotation, when maxAnswers truncates the answer slice.
+	policyWeighted policy = "weighted"
+)
+
+// weightAnnotation holds a pod's relative weight for policyWeighted.
+const weightAnnotation = "shturval.link/weight"
+
+// weightedAnswer pairs a built RR with the weight of the object it came
+// from, so policyWeighted can sample proportionally to weight.
+type weightedAnswer struct {
+	rr     dns.RR
+	weight int
+}
+
+// recordWeight returns obj's relative weight for policyWeighted: a pod's
+// weightAnnotation value, or 1 if unset, invalid, or obj isn't a Pod.
+func recordWeight(obj interface{}) int {
+	pod, ok := obj.(*core.Pod)
+	if !ok {
+		return 1
+	}
+
+	v, ok := pod.Annotations[weightAnnotation]
+	if !ok {
+		return 1
+	}
+
+	w, err := strconv.Atoi(v)
+	if err != nil || w <= 0 {
+		return 1
+	}
+	return w
+}
+
+// applyPolicy orders and, if maxAnswers is set, trims answers according to
+// k.policy.
+func (k *KubeHostport) applyPolicy(qname string, answers []weightedAnswer) []dns.RR {
+	switch k.policy {
+	case policyRoundRobin:
+		answers = rotate(answers, k.nextRoundRobin(qname))
+	case policyRandom:
+		rand.Shuffle(len(answers), func(i, j int) { answers[i], answers[j] = answers[j], answers[i] })
+	case policyWeighted:
+		if k.maxAnswers > 0 && k.maxAnswers < len(answers) {
+			answers = weightedSample(answers, k.maxAnswers)
+		}
+	}
+
+	if k.policy != policyWeighted && k.maxAnswers > 0 && k.maxAnswers < len(answers) {
+		answers = answers[:k.maxAnswers]
+	}
+
+	rrs := make([]dns.RR, len(answers))
+	for i, a := range answers {
+		rrs[i] = a.rr
+	}
+	return rrs
+}
+
+// rotate returns answers rotated left by n positions, wrapping around.
+func rotate(answers []weightedAnswer, n uint32) []weightedAnswer {
+	if len(answers) == 0 {
+		return answers
+	}
+	start := int(n) % len(answers)
+	rotated := make([]weightedAnswer, len(answers))
+	copy(rotated, answers[start:])
+	copy(rotated[len(answers)-start:], answers[:start])
+	return rotated
+}
+
+// nextRoundRobin returns the next rotation offset for qname, incrementing an
+// atomic counter kept per-qname across queries. qname is case-folded before
+// use as a map key: DNS queries are case-insensitive, so keying on the
+// client's original casing would let rrCounters grow without bound.
+func (k *KubeHostport) nextRoundRobin(qname string) uint32 {
+	key := strings.ToLower(qname)
+
+	k.rrMu.Lock()
+	c, ok := k.rrCounters[key]
+	if !ok {
+		c = new(uint32)
+		k.rrCounters[key] = c
+	}
+	k.rrMu.Unlock()
+
+	return atomic.AddUint32(c, 1)
+}
+
+// weightedSample picks n answers from answers via weighted reservoir
+// sampling (Efraimidis-Spirakis A-Res), proportional to recordWeight.
+func weightedSample(answers []weightedAnswer, n int) []weightedAnswer {
+	type keyed struct {
+		answer weightedAnswer
+		key    float64
+	}
+
+	keys := make([]keyed, len(answers))
+	for i, a := range answers {
+		w := a.weight
+		if w <= 0 {
+			w = 1
+		}
+		keys[i] = keyed{answer: a, key: math.Pow(rand.Float64(), 1/float64(w))}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key > keys[j].key })
+
+	out := make([]weightedAnswer, n)
+	for i := 0; i < n; i++ {
+		out[i] = keys[i].answer
+	}
+	return out
+}
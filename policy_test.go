@@ -0,0 +1,81 @@
+package kubehostport
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRotate(t *testing.T) {
+	mk := func(n int) []weightedAnswer {
+		answers := make([]weightedAnswer, n)
+		for i := range answers {
+			answers[i] = weightedAnswer{rr: &dns.A{Hdr: dns.RR_Header{Name: string(rune('a' + i))}}}
+		}
+		return answers
+	}
+	names := func(answers []weightedAnswer) []string {
+		out := make([]string, len(answers))
+		for i, a := range answers {
+			out[i] = a.rr.Header().Name
+		}
+		return out
+	}
+
+	tests := []struct {
+		name string
+		n    uint32
+		want []string
+	}{
+		{"no rotation", 0, []string{"a", "b", "c", "d"}},
+		{"rotate by one", 1, []string{"b", "c", "d", "a"}},
+		{"rotate by len wraps to identity", 4, []string{"a", "b", "c", "d"}},
+		{"rotate beyond len wraps", 5, []string{"b", "c", "d", "a"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := names(rotate(mk(4), tc.n))
+			if len(got) != len(tc.want) {
+				t.Fatalf("rotate() = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("rotate() = %v, want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+
+	if got := rotate(nil, 3); len(got) != 0 {
+		t.Errorf("rotate(nil, 3) = %v, want empty", got)
+	}
+}
+
+func TestWeightedSample(t *testing.T) {
+	answers := []weightedAnswer{
+		{rr: &dns.A{Hdr: dns.RR_Header{Name: "a"}}, weight: 1},
+		{rr: &dns.A{Hdr: dns.RR_Header{Name: "b"}}, weight: 1},
+		{rr: &dns.A{Hdr: dns.RR_Header{Name: "c"}}, weight: 1},
+	}
+
+	got := weightedSample(answers, 2)
+	if len(got) != 2 {
+		t.Fatalf("weightedSample() returned %d answers, want 2", len(got))
+	}
+
+	seen := map[string]bool{}
+	for _, a := range got {
+		seen[a.rr.Header().Name] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("weightedSample() returned duplicate answers: %v", got)
+	}
+}
+
+func TestRecordWeight(t *testing.T) {
+	if w := recordWeight("not a pod"); w != 1 {
+		t.Errorf("recordWeight(non-pod) = %d, want 1", w)
+	}
+}
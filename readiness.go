@@ -0,0 +1,60 @@
+package kubehostport
+
+import (
+	core "k8s.io/api/core/v1"
+)
+
+// readinessMode selects what checkPodRequirements treats as "ready".
+type readinessMode string
+
+const (
+	// readinessPod gates on the pod-level PodReady condition (the default).
+	readinessPod readinessMode = "pod"
+
+	// readinessContainer gates on a single named container's Ready status,
+	// for sidecar-heavy pods whose "serving" container diverges from the
+	// aggregate PodReady condition.
+	readinessContainer readinessMode = "container"
+
+	// readinessAny is satisfied as soon as any one container is Ready.
+	readinessAny readinessMode = "any"
+
+	// readinessCondition gates on an arbitrary pod condition, such as
+	// ContainersReady or a custom condition declared via
+	// pod.Spec.ReadinessGates.
+	readinessCondition readinessMode = "condition"
+)
+
+// podReady reports whether pod is ready to be resolved, per k.readinessMode.
+func (k *KubeHostport) podReady(pod *core.Pod) bool {
+	switch k.readinessMode {
+	case readinessContainer:
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name == k.readinessContainerName {
+				return cs.Ready
+			}
+		}
+		return false
+	case readinessAny:
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Ready {
+				return true
+			}
+		}
+		return false
+	case readinessCondition:
+		for _, c := range pod.Status.Conditions {
+			if c.Type == k.readinessConditionType {
+				return c.Status == core.ConditionTrue
+			}
+		}
+		return false
+	default:
+		for _, c := range pod.Status.Conditions {
+			if c.Type == core.PodReady {
+				return c.Status == core.ConditionTrue
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,95 @@
+package kubehostport
+
+import (
+	"testing"
+
+	core "k8s.io/api/core/v1"
+)
+
+func TestPodReady(t *testing.T) {
+	tests := []struct {
+		name string
+		k    *KubeHostport
+		pod  *core.Pod
+		want bool
+	}{
+		{
+			name: "default mode, PodReady true",
+			k:    &KubeHostport{},
+			pod: &core.Pod{Status: core.PodStatus{Conditions: []core.PodCondition{
+				{Type: core.PodReady, Status: core.ConditionTrue},
+			}}},
+			want: true,
+		},
+		{
+			name: "default mode, PodReady false",
+			k:    &KubeHostport{},
+			pod: &core.Pod{Status: core.PodStatus{Conditions: []core.PodCondition{
+				{Type: core.PodReady, Status: core.ConditionFalse},
+			}}},
+			want: false,
+		},
+		{
+			name: "default mode, no PodReady condition at all",
+			k:    &KubeHostport{},
+			pod:  &core.Pod{},
+			want: true,
+		},
+		{
+			name: "container mode, named container ready",
+			k:    &KubeHostport{readinessMode: readinessContainer, readinessContainerName: "app"},
+			pod: &core.Pod{Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{
+				{Name: "sidecar", Ready: false},
+				{Name: "app", Ready: true},
+			}}},
+			want: true,
+		},
+		{
+			name: "container mode, named container missing",
+			k:    &KubeHostport{readinessMode: readinessContainer, readinessContainerName: "app"},
+			pod: &core.Pod{Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{
+				{Name: "sidecar", Ready: true},
+			}}},
+			want: false,
+		},
+		{
+			name: "any mode, one container ready",
+			k:    &KubeHostport{readinessMode: readinessAny},
+			pod: &core.Pod{Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{
+				{Name: "sidecar", Ready: false},
+				{Name: "app", Ready: true},
+			}}},
+			want: true,
+		},
+		{
+			name: "any mode, no containers ready",
+			k:    &KubeHostport{readinessMode: readinessAny},
+			pod: &core.Pod{Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{
+				{Name: "sidecar", Ready: false},
+			}}},
+			want: false,
+		},
+		{
+			name: "condition mode, matching condition true",
+			k:    &KubeHostport{readinessMode: readinessCondition, readinessConditionType: "ContainersReady"},
+			pod: &core.Pod{Status: core.PodStatus{Conditions: []core.PodCondition{
+				{Type: "ContainersReady", Status: core.ConditionTrue},
+			}}},
+			want: true,
+		},
+		{
+			name: "condition mode, condition absent",
+			k:    &KubeHostport{readinessMode: readinessCondition, readinessConditionType: "ContainersReady"},
+			pod:  &core.Pod{},
+			want: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.k.podReady(tc.pod); got != tc.want {
+				t.Errorf("podReady() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
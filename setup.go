@@ -14,10 +14,12 @@ import (
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/kubeapi"
 
 	core "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 )
 
 const pluginName = "coreha"
@@ -35,6 +37,10 @@ func setup(c *caddy.Controller) error {
 	k.setWatch(context.Background())
 	c.OnStartup(startWatch(k, dnsserver.GetConfig(c)))
 	c.OnShutdown(stopWatch(k))
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, requestCount, requestDuration, podsIndexed, podsFilteredCount, informerEventCount)
+		return nil
+	})
 
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		k.Next = next
@@ -116,6 +122,91 @@ func parseStanza(c *caddy.Controller) (*KubeHostport, error) {
 				return nil, c.Errf("ttl must be in range [0, 3600]: %d", t)
 			}
 			kns.ttl = uint32(t)
+		case "nodeAddressType":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			switch core.NodeAddressType(args[0]) {
+			case core.NodeInternalIP, core.NodeExternalIP, core.NodeHostName:
+				kns.nodeAddressType = core.NodeAddressType(args[0])
+			default:
+				return nil, c.Errf("nodeAddressType must be one of InternalIP, ExternalIP, Hostname: %s", args[0])
+			}
+		case "skipUnschedulable":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			skipUnschedulable, err := strconv.ParseBool(args[0])
+			if err != nil {
+				return nil, err
+			}
+			kns.skipUnschedulable = skipUnschedulable
+		case "source":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case "pods":
+				kns.source = sourcePods
+			case "endpointslices":
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				kns.source = sourceEndpointSlices
+				kns.endpointServiceName = args[1]
+			default:
+				return nil, c.Errf("source must be one of pods, endpointslices: %s", args[0])
+			}
+		case "policy":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			switch policy(args[0]) {
+			case policySequential, policyRoundRobin, policyRandom, policyWeighted:
+				kns.policy = policy(args[0])
+			default:
+				return nil, c.Errf("policy must be one of sequential, round_robin, random, weighted: %s", args[0])
+			}
+		case "maxAnswers":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				return nil, c.Errf("maxAnswers must be >= 0: %d", n)
+			}
+			kns.maxAnswers = n
+		case "readiness":
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return nil, c.ArgErr()
+			}
+			switch args[0] {
+			case "container":
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				kns.readinessMode = readinessContainer
+				kns.readinessContainerName = args[1]
+			case "any":
+				kns.readinessMode = readinessAny
+			case "condition":
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				kns.readinessMode = readinessCondition
+				kns.readinessConditionType = core.PodConditionType(args[1])
+			default:
+				return nil, c.Errf("readiness must be one of container, any, condition: %s", args[0])
+			}
 		default:
 			return nil, c.Errf("unknown property '%s'", c.Val())
 		}
@@ -125,6 +216,15 @@ func parseStanza(c *caddy.Controller) (*KubeHostport, error) {
 }
 
 func (k *KubeHostport) setWatch(ctx context.Context) {
+	if k.source == sourceEndpointSlices {
+		k.setWatchEndpointSlices(ctx)
+	} else {
+		k.setWatchPods(ctx)
+	}
+	k.setWatchNodes(ctx)
+}
+
+func (k *KubeHostport) setWatchPods(ctx context.Context) {
 	// define Pod controller and reverse lookup indexer
 	k.indexer, k.controller = cache.NewIndexerInformer(
 		&cache.ListWatch{
@@ -139,7 +239,7 @@ func (k *KubeHostport) setWatch(ctx context.Context) {
 		},
 		&core.Pod{},
 		0,
-		cache.ResourceEventHandlerFuncs{},
+		k.indexerEventHandlers(),
 		cache.Indexers{
 			"reverse": func(obj interface{}) ([]string, error) {
 				pod, ok := obj.(*core.Pod)
@@ -147,11 +247,11 @@ func (k *KubeHostport) setWatch(ctx context.Context) {
 					return nil, errors.New("unexpected obj type")
 				}
 
-				if !k.checkPodRequirements(pod) {
+				if ok, _ := k.checkPodRequirements(pod); !ok {
 					return nil, nil
 				}
 
-				return []string{pod.Status.HostIP}, nil
+				return []string{k.podHostIP(pod)}, nil
 			},
 			"labelValue": func(obj interface{}) ([]string, error) {
 				pod, ok := obj.(*core.Pod)
@@ -159,7 +259,7 @@ func (k *KubeHostport) setWatch(ctx context.Context) {
 					return nil, errors.New("unexpected obj type")
 				}
 
-				if !k.checkPodRequirements(pod) {
+				if ok, _ := k.checkPodRequirements(pod); !ok {
 					return nil, nil
 				}
 
@@ -169,10 +269,108 @@ func (k *KubeHostport) setWatch(ctx context.Context) {
 				}
 				return []string{labelValue}, nil
 			},
+			"srv": func(obj interface{}) ([]string, error) {
+				pod, ok := obj.(*core.Pod)
+				if !ok {
+					return nil, errors.New("unexpected obj type")
+				}
+
+				if ok, _ := k.checkPodRequirements(pod); !ok {
+					return nil, nil
+				}
+
+				service, ok := pod.Labels[k.labelKey]
+				if !ok {
+					return nil, nil
+				}
+
+				var keys []string
+				for _, c := range pod.Spec.Containers {
+					for _, port := range c.Ports {
+						if port.HostPort == 0 {
+							continue
+						}
+
+						proto := port.Protocol
+						if proto == "" {
+							proto = core.ProtocolTCP
+						}
+						keys = append(keys, srvIndexKey(service, string(proto)))
+					}
+				}
+				return keys, nil
+			},
 		},
 	)
 }
 
+// setWatchEndpointSlices watches the EndpointSlices backing
+// endpointServiceName instead of Pods directly, reusing the Ready semantics
+// Kubernetes already computes for Services.
+func (k *KubeHostport) setWatchEndpointSlices(ctx context.Context) {
+	selector := discoveryv1.LabelServiceName + "=" + k.endpointServiceName
+
+	k.indexer, k.controller = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(o v1.ListOptions) (runtime.Object, error) {
+				o.LabelSelector = selector
+				return k.client.DiscoveryV1().EndpointSlices(k.namespace).List(ctx, o)
+			},
+			WatchFunc: func(o v1.ListOptions) (watch.Interface, error) {
+				o.LabelSelector = selector
+				return k.client.DiscoveryV1().EndpointSlices(k.namespace).Watch(ctx, o)
+			},
+		},
+		&discoveryv1.EndpointSlice{},
+		0,
+		k.indexerEventHandlers(),
+		cache.Indexers{
+			"reverse": func(obj interface{}) ([]string, error) {
+				slice, ok := obj.(*discoveryv1.EndpointSlice)
+				if !ok {
+					return nil, errors.New("unexpected obj type")
+				}
+
+				var addrs []string
+				for _, ep := range slice.Endpoints {
+					if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+						continue
+					}
+					addrs = append(addrs, ep.Addresses...)
+				}
+				return addrs, nil
+			},
+			"labelValue": func(obj interface{}) ([]string, error) {
+				slice, ok := obj.(*discoveryv1.EndpointSlice)
+				if !ok {
+					return nil, errors.New("unexpected obj type")
+				}
+
+				return []string{k.endpointServiceName + "." + slice.Namespace}, nil
+			},
+		},
+	)
+}
+
+// setWatchNodes watches Nodes, used to resolve nodeAddressType and to filter
+// pods on cordoned or not-ready nodes when skipUnschedulable is set.
+func (k *KubeHostport) setWatchNodes(ctx context.Context) {
+	k.nodeIndexer, k.nodeController = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(o v1.ListOptions) (runtime.Object, error) {
+				return k.client.CoreV1().Nodes().List(ctx, o)
+			},
+			WatchFunc: func(o v1.ListOptions) (watch.Interface, error) {
+				return k.client.CoreV1().Nodes().Watch(ctx, o)
+			},
+		},
+		&core.Node{},
+		0,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{},
+	)
+}
+
 func startWatch(k *KubeHostport, config *dnsserver.Config) func() error {
 	return func() error {
 		// retrieve client from kubeapi plugin
@@ -182,8 +380,9 @@ func startWatch(k *KubeHostport, config *dnsserver.Config) func() error {
 			return err
 		}
 
-		// start the informer
+		// start the informers
 		go k.controller.Run(k.stopCh)
+		go k.nodeController.Run(k.stopCh)
 		return nil
 	}
 }
@@ -201,48 +400,59 @@ func stopWatch(k *KubeHostport) func() error {
 	}
 }
 
-// checkPodRequirements checks if a pod meets the requirements for being resolved by the KubeHostport plugin.
-// It verifies that the pod is running, has a hostIP, has the required label (with optional value), and if strictHostPort is set, it also checks if the pod has a hostPort.
-// Parameters:
-// - pod: The pod to be checked.
-// Returns:
-// - bool: true if the pod meets the requirements, false otherwise.
-func (k *KubeHostport) checkPodRequirements(pod *core.Pod) bool {
+// checkPodRequirements reports whether pod meets the requirements for being
+// resolved by the KubeHostport plugin, and if not, a reason suitable for the
+// podsFilteredCount metric. It is a pure predicate: it is called from all
+// three pod IndexFuncs in setWatchPods as well as once per Add/Update event (see
+// recordPodFilterReason in metrics.go), so it must not have side effects of
+// its own.
+func (k *KubeHostport) checkPodRequirements(pod *core.Pod) (ok bool, reason string) {
 	// sanity check
 	if pod == nil {
-		return false
+		return false, "nil-pod"
 	}
 
-	// Exclude pod with Terminating state or without hostIp
-	if pod.DeletionTimestamp != nil || pod.Status.HostIP == "" {
-		return false
+	// Exclude pod with Terminating state
+	if pod.DeletionTimestamp != nil {
+		return false, "not-ready"
 	}
 
-	// Get only Ready pods
-	for _, c := range pod.Status.Conditions {
-		if c.Type == core.PodReady && c.Status != core.ConditionTrue {
-			return false
-		}
+	// Exclude pods without a hostIP
+	if pod.Status.HostIP == "" {
+		return false, "no-hostip"
+	}
+
+	// Get only Ready pods, per the configured readiness mode
+	if !k.podReady(pod) {
+		return false, "not-ready"
 	}
-	// check if the pod is running and has a hostIP
-	// if pod.Status.Phase != core.PodRunning || pod.Status.HostIP == "" {
-	// 	return false
-	// }
 
 	// check if the pod has the required label
-	val, ok := pod.Labels[k.labelKey]
-	if !ok {
-		return false
+	val, labeled := pod.Labels[k.labelKey]
+	if !labeled {
+		return false, "no-label"
 	}
 
 	// check label value if required
 	if k.labelVal != "" && val != k.labelVal {
-		return false
+		return false, "no-label"
+	}
+
+	// drop pods whose node is cordoned or not ready. The node is checked
+	// only when the pod itself is (re)indexed: a change to the Node alone
+	// does not re-index its pods, and a node not yet in nodeIndexer lets
+	// the pod through.
+	if k.skipUnschedulable {
+		if node, ok := k.getNode(pod.Spec.NodeName); ok {
+			if node.Spec.Unschedulable || !nodeReady(node) {
+				return false, "not-ready"
+			}
+		}
 	}
 
 	// if strictHostPort is not set, we can return early
 	if !k.strictHostPort {
-		return true
+		return true, ""
 	}
 
 	// check if the pod has a hostPort
@@ -255,7 +465,11 @@ func (k *KubeHostport) checkPodRequirements(pod *core.Pod) bool {
 		}
 	}
 
-	return hasHostPort
+	if !hasHostPort {
+		return false, "no-hostport"
+	}
+
+	return true, ""
 }
 
 // getPodLabelValueWithNamespace returns the value of the specified label key for the given pod,